@@ -0,0 +1,156 @@
+package pmdauth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// userAccessTokenAudience identifies a personal access token in the JWT's aud claim,
+// distinguishing it from an ordinary session token
+const userAccessTokenAudience = "user.access-token"
+
+var accessTokenCollection *mongo.Collection
+
+// UserAccessToken describes a personal access token a user has minted
+type UserAccessToken struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID     primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Jti        string             `json:"jti" bson:"jti"`
+	Name       string             `json:"name" bson:"name"`
+	CreatedAt  time.Time          `json:"created_at" bson:"created_at"`
+	LastUsedAt time.Time          `json:"last_used_at" bson:"last_used_at"`
+	Deleted    bool               `json:"-" bson:"deleted"`
+}
+
+// CreateUserAccessToken mints a long-lived, named JWT for a user that can be
+// used in place of a browser session token, optionally expiring at expiresAt.
+func CreateUserAccessToken(userID primitive.ObjectID, name string, expiresAt *time.Time) (token string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return CreateUserAccessTokenCtx(ctx, userID, name, expiresAt)
+}
+
+// CreateUserAccessTokenCtx mints a personal access token like
+// CreateUserAccessToken, but lets the caller control cancellation and
+// deadlines via ctx.
+func CreateUserAccessTokenCtx(ctx context.Context, userID primitive.ObjectID, name string, expiresAt *time.Time) (token string, err error) {
+	if initError != nil {
+		return "", initError
+	}
+
+	jti := primitive.NewObjectID().Hex()
+
+	registered := jwt.RegisteredClaims{
+		ID:       jti,
+		IssuedAt: jwt.NewNumericDate(time.Now()),
+		Subject:  userID.Hex(),
+		Audience: jwt.ClaimStrings{userAccessTokenAudience},
+	}
+	if expiresAt != nil {
+		registered.ExpiresAt = jwt.NewNumericDate(*expiresAt)
+	}
+
+	claims := &Claims{
+		UserID:           userID,
+		Role:             "user",
+		RegisteredClaims: registered,
+	}
+
+	signed, err := signClaims(claims)
+	if err != nil {
+		return "", err
+	}
+
+	record := UserAccessToken{
+		UserID:    userID,
+		Jti:       jti,
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+
+	if _, err = accessTokenCollection.InsertOne(ctx, record); err != nil {
+		return "", err
+	}
+
+	return signed, nil
+}
+
+// ListUserAccessTokens returns the personal access tokens a user has created,
+// excluding any that have been deleted.
+func ListUserAccessTokens(userID primitive.ObjectID) ([]UserAccessToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return ListUserAccessTokensCtx(ctx, userID)
+}
+
+// ListUserAccessTokensCtx lists a user's personal access tokens like
+// ListUserAccessTokens, but lets the caller control cancellation and
+// deadlines via ctx.
+func ListUserAccessTokensCtx(ctx context.Context, userID primitive.ObjectID) ([]UserAccessToken, error) {
+	if initError != nil {
+		return nil, initError
+	}
+
+	cursor, err := accessTokenCollection.Find(ctx, bson.M{"user_id": userID, "deleted": bson.M{"$ne": true}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var tokens []UserAccessToken
+	if err = cursor.All(ctx, &tokens); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// DeleteUserAccessToken revokes a personal access token belonging to a user
+// by marking its record deleted.
+func DeleteUserAccessToken(userID, tokenID primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return DeleteUserAccessTokenCtx(ctx, userID, tokenID)
+}
+
+// DeleteUserAccessTokenCtx revokes a personal access token like
+// DeleteUserAccessToken, but lets the caller control cancellation and
+// deadlines via ctx.
+func DeleteUserAccessTokenCtx(ctx context.Context, userID, tokenID primitive.ObjectID) error {
+	if initError != nil {
+		return initError
+	}
+
+	result, err := accessTokenCollection.UpdateOne(ctx,
+		bson.M{"_id": tokenID, "user_id": userID},
+		bson.M{"$set": bson.M{"deleted": true}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return errors.New("access token not found")
+	}
+
+	return nil
+}
+
+// checkUserAccessToken verifies that a personal access token's jti is on record
+// and hasn't been deleted, and bumps its last_used_at timestamp.
+func checkUserAccessToken(ctx context.Context, jti string) error {
+	var record UserAccessToken
+	err := accessTokenCollection.FindOne(ctx, bson.M{"jti": jti, "deleted": bson.M{"$ne": true}}).Decode(&record)
+	if err != nil {
+		return errors.New("access token not found or revoked")
+	}
+
+	_, err = accessTokenCollection.UpdateOne(ctx, bson.M{"jti": jti}, bson.M{"$set": bson.M{"last_used_at": time.Now()}})
+	return err
+}
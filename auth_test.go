@@ -0,0 +1,274 @@
+package pmdauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newTestUser(email string) User {
+	return User{
+		ID:        primitive.NewObjectID(),
+		Email:     email,
+		Name:      "Test User",
+		Role:      "user",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+// resetGlobalsForTest points the package at an InMemoryUserStore and a fixed
+// HS256 secret, bypassing once.Do so each test gets a clean slate.
+func resetGlobalsForTest(t *testing.T) *InMemoryUserStore {
+	t.Helper()
+
+	initError = nil
+	jwtAlgorithm = AlgorithmHS256
+	jwtSecret = "test-jwt-secret-key"
+	devModeEnabled = false
+
+	store := NewInMemoryUserStore()
+	userStore = store
+	return store
+}
+
+func TestValidateTokenWithInMemoryStore(t *testing.T) {
+	store := resetGlobalsForTest(t)
+
+	user := newTestUser("validate-test@example.com")
+	if err := store.Insert(context.Background(), &user); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	token, err := GenerateToken(user, 1)
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+
+	validated, err := ValidateToken(token)
+	if err != nil {
+		t.Fatalf("ValidateToken failed: %v", err)
+	}
+	if validated.Email != user.Email {
+		t.Errorf("expected email %s, got %s", user.Email, validated.Email)
+	}
+
+	if _, err = ValidateToken("not-a-token"); err == nil {
+		t.Error("expected ValidateToken to reject a malformed token")
+	}
+}
+
+func TestGenerateTokenWithOptionsAudience(t *testing.T) {
+	store := resetGlobalsForTest(t)
+
+	user := newTestUser("options-test@example.com")
+	user.Role = "admin"
+	if err := store.Insert(context.Background(), &user); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	// The claim's role ("dev") must never override the store's live role
+	// ("admin"): role is authoritative from the DB fetch, not the token.
+	token, err := GenerateTokenWithOptions(user, TokenOptions{Role: "dev", Audience: "custom-audience", ExpireHours: 1})
+	if err != nil {
+		t.Fatalf("GenerateTokenWithOptions failed: %v", err)
+	}
+
+	if _, err = ValidateTokenForAudience(token, "custom-audience"); err != nil {
+		t.Errorf("expected token to validate for its own audience: %v", err)
+	}
+	if _, err = ValidateTokenForAudience(token, "other-audience"); err == nil {
+		t.Error("expected ValidateTokenForAudience to reject a mismatched audience")
+	}
+
+	validated, err := ValidateToken(token)
+	if err != nil {
+		t.Fatalf("ValidateToken failed: %v", err)
+	}
+	if validated.Role != "admin" {
+		t.Errorf("expected role to come from the store (admin), got %s", validated.Role)
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	admin := &User{Role: "admin"}
+	if err := RequireRole(admin, "user", "admin"); err != nil {
+		t.Errorf("expected admin to satisfy RequireRole: %v", err)
+	}
+	if err := RequireRole(admin, "user"); err == nil {
+		t.Error("expected RequireRole to reject a role not in the allowed list")
+	}
+	if err := RequireRole(nil, "user"); err == nil {
+		t.Error("expected RequireRole to reject a nil user")
+	}
+}
+
+// TestRefreshTokenRejectedAsAccessToken is a regression test: a refresh token
+// must never pass ValidateToken, since that would skip the DB-backed
+// revocation/expiry checks RefreshToken performs.
+func TestRefreshTokenRejectedAsAccessToken(t *testing.T) {
+	store := resetGlobalsForTest(t)
+
+	user := newTestUser("refresh-reject-test@example.com")
+	if err := store.Insert(context.Background(), &user); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	refreshToken, _, _, err := signRefreshToken(user, 24)
+	if err != nil {
+		t.Fatalf("signRefreshToken failed: %v", err)
+	}
+
+	if _, err = ValidateToken(refreshToken); err == nil {
+		t.Error("expected ValidateToken to reject a refresh token")
+	}
+}
+
+func TestPublicJWKSIncludesRegisteredKeys(t *testing.T) {
+	verificationKeysMu.Lock()
+	verificationKeys = map[string]interface{}{}
+	verificationKeysMu.Unlock()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal public key: %v", err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	kid, err := AddVerificationKey(AlgorithmRS256, publicPEM)
+	if err != nil {
+		t.Fatalf("AddVerificationKey failed: %v", err)
+	}
+
+	jwks, err := PublicJWKS()
+	if err != nil {
+		t.Fatalf("PublicJWKS failed: %v", err)
+	}
+	if !strings.Contains(string(jwks), kid) {
+		t.Errorf("expected JWKS to contain kid %q, got %s", kid, jwks)
+	}
+}
+
+// TestTokenKeyFuncRejectsAlgorithmConfusion is a regression test: when the
+// library is configured for RS256, a token forged with HS256 and signed
+// using the empty-string key (jwtSecret is never set in this configuration)
+// must not verify just because the token itself claims HS256.
+func TestTokenKeyFuncRejectsAlgorithmConfusion(t *testing.T) {
+	store := resetGlobalsForTest(t)
+	jwtSecret = ""
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal public key: %v", err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	kid, err := AddVerificationKey(AlgorithmRS256, publicPEM)
+	if err != nil {
+		t.Fatalf("AddVerificationKey failed: %v", err)
+	}
+
+	jwtAlgorithm = AlgorithmRS256
+	signingKey = privateKey
+	signingKid = kid
+	defer func() { jwtAlgorithm = AlgorithmHS256 }()
+
+	user := newTestUser("alg-confusion-test@example.com")
+	user.Role = "admin"
+	if err := store.Insert(context.Background(), &user); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	forgedClaims := &Claims{
+		UserID: user.ID,
+		Role:   "admin",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID.Hex(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	forgedToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, forgedClaims).SignedString([]byte(jwtSecret))
+	if err != nil {
+		t.Fatalf("failed to sign forged token: %v", err)
+	}
+
+	if _, err = ValidateToken(forgedToken); err == nil {
+		t.Error("expected ValidateToken to reject a token forged with a different algorithm than configured")
+	}
+
+	legitToken, err := GenerateToken(user, 1)
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+	if _, err = ValidateToken(legitToken); err != nil {
+		t.Errorf("expected a correctly-signed RS256 token to validate: %v", err)
+	}
+}
+
+// TestRefreshTokenRotationAndRevocation exercises GenerateTokenPair,
+// RefreshToken, and RevokeRefreshToken against a real refresh_tokens
+// collection (uses the same test MongoDB as setupTestDB, since
+// refreshCollection is not abstracted behind UserStore).
+func TestRefreshTokenRotationAndRevocation(t *testing.T) {
+	collection := setupTestDB(t)
+	defer cleanupTestDB(t, collection)
+
+	client := collection.Database().Client()
+	refreshCollection = client.Database(collection.Database().Name()).Collection("refresh_tokens_test")
+	defer func() {
+		_, _ = refreshCollection.DeleteMany(context.Background(), bson.M{})
+	}()
+
+	user := newTestUser("refresh-rotation-test@example.com")
+	if err := userStore.Insert(context.Background(), &user); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	_, refreshToken, err := GenerateTokenPair(user, 1, 24)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair failed: %v", err)
+	}
+
+	newAccess, newRefresh, err := RefreshToken(refreshToken)
+	if err != nil {
+		t.Fatalf("RefreshToken failed: %v", err)
+	}
+	if newAccess == "" || newRefresh == "" {
+		t.Fatal("expected RefreshToken to return new tokens")
+	}
+
+	if _, _, err = RefreshToken(refreshToken); err == nil {
+		t.Error("expected the rotated-out refresh token to be rejected")
+	}
+
+	newClaims := &RefreshClaims{}
+	if _, _, err = jwt.NewParser().ParseUnverified(newRefresh, newClaims); err != nil {
+		t.Fatalf("failed to parse rotated refresh token: %v", err)
+	}
+
+	if err = RevokeRefreshToken(newClaims.ID); err != nil {
+		t.Fatalf("RevokeRefreshToken failed: %v", err)
+	}
+
+	if _, _, err = RefreshToken(newRefresh); err == nil {
+		t.Error("expected a revoked refresh token to be rejected")
+	}
+}
@@ -0,0 +1,67 @@
+package pmdauth
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Config configures the auth library explicitly, bypassing the
+// environment-variable driven setup InitAuthLib performs.
+type Config struct {
+	// Store is the UserStore backing all user lookups. Required.
+	Store UserStore
+	// JWTSecret signs and verifies HS256 tokens. Required unless Algorithm
+	// is RS256 or ES256.
+	JWTSecret string
+	// Algorithm selects the signing algorithm: HS256 (default), RS256, or
+	// ES256. RS256/ES256 require JWTPrivateKeyPath and JWTPublicKeyPath.
+	Algorithm string
+	// JWTPrivateKeyPath/JWTPublicKeyPath point at PEM-encoded key files
+	// used to sign and verify tokens when Algorithm is RS256 or ES256.
+	JWTPrivateKeyPath string
+	JWTPublicKeyPath  string
+	// DevMode, when true, allows GenerateDevToken to mint "dev"-role tokens.
+	DevMode bool
+}
+
+// InitAuthLibWithConfig sets up the auth library from an explicit Config,
+// letting callers supply their own UserStore instead of the MongoDB-backed
+// one InitAuthLib builds from the environment. Like InitAuthLib, it only
+// takes effect on the first call.
+func InitAuthLibWithConfig(cfg Config) {
+	once.Do(func() {
+		applyConfig(cfg)
+	})
+}
+
+func applyConfig(cfg Config) {
+	if cfg.Store == nil {
+		initError = errors.New("missing required config: Store must be set")
+		return
+	}
+
+	algorithm := cfg.Algorithm
+	if algorithm == "" {
+		algorithm = AlgorithmHS256
+	}
+
+	switch algorithm {
+	case AlgorithmHS256:
+		if cfg.JWTSecret == "" {
+			initError = errors.New("missing required config: JWTSecret must be set for HS256")
+			return
+		}
+		jwtSecret = cfg.JWTSecret
+	case AlgorithmRS256, AlgorithmES256:
+		if err := loadSigningKeys(algorithm, cfg.JWTPrivateKeyPath, cfg.JWTPublicKeyPath); err != nil {
+			initError = err
+			return
+		}
+	default:
+		initError = fmt.Errorf("unsupported algorithm %q", algorithm)
+		return
+	}
+
+	userStore = cfg.Store
+	devModeEnabled = cfg.DevMode
+}
@@ -4,6 +4,7 @@ package pmdauth
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"os"
 	"sync"
@@ -11,7 +12,6 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/joho/godotenv"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -25,6 +25,7 @@ type User struct {
 	Name      string             `json:"name" bson:"name"`
 	Picture   string             `json:"picture" bson:"picture"`
 	Status    int                `json:"status" bson:"status"`
+	Role      string             `json:"role" bson:"role,omitempty"`
 	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
 }
@@ -34,18 +35,30 @@ type Claims struct {
 	UserID   primitive.ObjectID `json:"user_id"`
 	GoogleID string             `json:"google_id"`
 	Email    string             `json:"email"`
+	Role     string             `json:"role"`
 	jwt.RegisteredClaims
 }
 
+// TokenOptions controls the claims embedded in a token minted via
+// GenerateTokenWithOptions
+type TokenOptions struct {
+	Role        string
+	Audience    string
+	ExpireHours int
+}
+
 var (
-	jwtSecret       string
-	mongoCollection *mongo.Collection
-	once            sync.Once
-	initError       error
-	devToken        string
+	jwtSecret         string
+	mongoClient       *mongo.Client
+	refreshCollection *mongo.Collection
+	once              sync.Once
+	initError         error
+	devModeEnabled    bool
 )
 
-// InitAuthLib automatically sets up the auth library from environment variables
+// InitAuthLib automatically sets up the auth library from environment
+// variables, connecting to MongoDB and using it as the UserStore. For a
+// non-MongoDB UserStore, use InitAuthLibWithConfig instead.
 func InitAuthLib() {
 	once.Do(func() {
 		// Load .env file if it exists (ignore error if not found)
@@ -54,11 +67,16 @@ func InitAuthLib() {
 		// Read environment variables
 		mongoURI := os.Getenv("MONGODB_URI")
 		mongoDB := os.Getenv("MONGODB_DATABASE")
-		jwtSecret = os.Getenv("JWT_SECRET")
-		devToken = os.Getenv("DEV_TOKEN")
+		secret := os.Getenv("JWT_SECRET")
+		algorithm := os.Getenv("JWT_ALGORITHM")
+		devMode := os.Getenv("PMD_AUTH_DEV_MODE") == "true"
 
-		if mongoURI == "" || mongoDB == "" || jwtSecret == "" {
-			initError = errors.New("missing required environment variables: MONGODB_URI, MONGODB_DATABASE, JWT_SECRET")
+		if mongoURI == "" || mongoDB == "" {
+			initError = errors.New("missing required environment variables: MONGODB_URI, MONGODB_DATABASE")
+			return
+		}
+		if algorithm == "" && secret == "" {
+			initError = errors.New("missing required environment variable: JWT_SECRET")
 			return
 		}
 
@@ -80,75 +98,258 @@ func InitAuthLib() {
 			return
 		}
 
-		mongoCollection = client.Database(mongoDB).Collection("users")
-		log.Println("Auth library initialized successfully")
+		db := client.Database(mongoDB)
+		usersCollection := db.Collection("users")
+		if err = ensureUserIndexes(ctx, usersCollection); err != nil {
+			initError = err
+			return
+		}
+
+		mongoClient = client
+		refreshCollection = db.Collection("refresh_tokens")
+		accessTokenCollection = db.Collection("user_access_tokens")
+
+		applyConfig(Config{
+			Store:             NewMongoUserStore(usersCollection),
+			JWTSecret:         secret,
+			Algorithm:         algorithm,
+			JWTPrivateKeyPath: os.Getenv("JWT_PRIVATE_KEY_PATH"),
+			JWTPublicKeyPath:  os.Getenv("JWT_PUBLIC_KEY_PATH"),
+			DevMode:           devMode,
+		})
+		if initError == nil {
+			log.Println("Auth library initialized successfully")
+		}
 	})
 }
 
 // ValidateToken validates a JWT token and returns the user if valid
 func ValidateToken(tokenString string) (*User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return validateToken(ctx, tokenString, "")
+}
+
+// ValidateTokenForAudience validates a JWT token and additionally enforces
+// that its aud claim contains expectedAudience, per RFC 7519.
+func ValidateTokenForAudience(tokenString, expectedAudience string) (*User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return validateTokenForAudienceCtx(ctx, tokenString, expectedAudience)
+}
+
+// ValidateTokenCtx validates a JWT token like ValidateToken, but lets the
+// caller control cancellation and deadlines instead of using a fixed timeout.
+func ValidateTokenCtx(ctx context.Context, tokenString string) (*User, error) {
+	return validateToken(ctx, tokenString, "")
+}
+
+// ValidateTokenForAudienceCtx validates a JWT token like
+// ValidateTokenForAudience, but lets the caller control cancellation and
+// deadlines via ctx.
+func ValidateTokenForAudienceCtx(ctx context.Context, tokenString, expectedAudience string) (*User, error) {
+	return validateTokenForAudienceCtx(ctx, tokenString, expectedAudience)
+}
+
+func validateTokenForAudienceCtx(ctx context.Context, tokenString, expectedAudience string) (*User, error) {
+	if expectedAudience == "" {
+		return nil, errors.New("expectedAudience must not be empty")
+	}
+	return validateToken(ctx, tokenString, expectedAudience)
+}
+
+func validateToken(ctx context.Context, tokenString, expectedAudience string) (*User, error) {
 	if initError != nil {
 		return nil, initError
 	}
 
-	isDevToken := tokenString == devToken
-
 	// Parse and validate JWT
 	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(jwtSecret), nil
-	})
-
-	if err != nil && !isDevToken {
+	token, err := jwt.ParseWithClaims(tokenString, claims, tokenKeyFunc)
+	if err != nil {
 		return nil, err
 	}
 
-	if !token.Valid && !isDevToken {
+	if !token.Valid {
 		return nil, errors.New("invalid token")
 	}
 
-	// Get user from database
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	if audienceContains(claims.Audience, refreshTokenAudience) {
+		return nil, errors.New("refresh tokens cannot be used as access tokens")
+	}
+
+	if expectedAudience != "" {
+		if !audienceContains(claims.Audience, expectedAudience) {
+			return nil, errors.New("token audience does not match expected audience")
+		}
+	}
 
+	if audienceContains(claims.Audience, userAccessTokenAudience) {
+		if err = checkUserAccessToken(ctx, claims.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Get user from the store
 	objectID, err := primitive.ObjectIDFromHex(claims.Subject)
 	if err != nil {
 		return nil, errors.New("invalid user ID in token")
 	}
 
-	var user User
-	err = mongoCollection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&user)
+	user, err := userStore.FindByID(ctx, objectID)
 	if err != nil {
 		return nil, errors.New("user not found")
 	}
 
-	return &user, nil
+	return user, nil
+}
+
+func audienceContains(audience jwt.ClaimStrings, want string) bool {
+	for _, aud := range audience {
+		if aud == want {
+			return true
+		}
+	}
+	return false
 }
 
 // GenerateToken creates a JWT token for a user
 func GenerateToken(user User, expireHours int) (string, error) {
+	return GenerateTokenWithOptions(user, TokenOptions{Role: user.Role, ExpireHours: expireHours})
+}
+
+// GenerateTokenCtx creates a JWT token for a user like GenerateToken, but
+// lets the caller control cancellation and deadlines via ctx.
+func GenerateTokenCtx(ctx context.Context, user User, expireHours int) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return GenerateToken(user, expireHours)
+}
+
+// GenerateTokenWithOptions creates a JWT token for a user with an explicit
+// role and audience, in addition to the standard expiry.
+func GenerateTokenWithOptions(user User, opts TokenOptions) (string, error) {
 	if initError != nil {
 		return "", initError
 	}
 
-	expirationTime := time.Now().Add(time.Duration(expireHours) * time.Hour)
+	role := opts.Role
+	if role == "" {
+		role = "user"
+	}
+
+	expirationTime := time.Now().Add(time.Duration(opts.ExpireHours) * time.Hour)
+
+	registered := jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(expirationTime),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		Subject:   user.ID.Hex(),
+	}
+	if opts.Audience != "" {
+		registered.Audience = jwt.ClaimStrings{opts.Audience}
+	}
 
 	claims := &Claims{
-		UserID:   user.ID,
-		GoogleID: user.GoogleID,
-		Email:    user.Email,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Subject:   user.ID.Hex(),
-		},
+		UserID:           user.ID,
+		GoogleID:         user.GoogleID,
+		Email:            user.Email,
+		Role:             role,
+		RegisteredClaims: registered,
+	}
+
+	return signClaims(claims)
+}
+
+// tokenKeyFunc selects the key used to verify a token's signature. It trusts
+// only the library's configured jwtAlgorithm, never the alg a token claims
+// for itself — otherwise a token forged with a different algorithm (e.g.
+// HS256 signed with the empty jwtSecret, when the library is configured for
+// RS256/ES256) would verify against whatever key that algorithm's branch picks.
+func tokenKeyFunc(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() != jwtAlgorithm {
+		return nil, fmt.Errorf("unexpected signing method %q", token.Method.Alg())
+	}
+
+	switch jwtAlgorithm {
+	case AlgorithmRS256, AlgorithmES256:
+		kid, _ := token.Header["kid"].(string)
+		key, ok := lookupVerificationKey(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key, nil
+	default:
+		return []byte(jwtSecret), nil
 	}
+}
+
+// signClaims signs claims with whichever algorithm the library was
+// configured with (HS256 by default, or RS256/ES256 when keys were loaded).
+func signClaims(claims jwt.Claims) (string, error) {
+	switch jwtAlgorithm {
+	case AlgorithmRS256:
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = signingKid
+		return token.SignedString(signingKey)
+	case AlgorithmES256:
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+		token.Header["kid"] = signingKid
+		return token.SignedString(signingKey)
+	default:
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(jwtSecret))
+	}
+}
+
+// GenerateDevToken mints a token carrying the "dev" role claim. It only
+// succeeds when the library was initialized with PMD_AUTH_DEV_MODE=true (or,
+// via InitAuthLibWithConfig, with Config.DevMode set); the resulting token is validated like any other signed token, it is never
+// exempt from signature or expiry checks.
+func GenerateDevToken(user User, expireHours int) (string, error) {
+	if !devModeEnabled {
+		return "", errors.New("dev tokens are disabled: set PMD_AUTH_DEV_MODE=true to enable")
+	}
+	return GenerateTokenWithOptions(user, TokenOptions{Role: "dev", ExpireHours: expireHours})
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(jwtSecret))
+// RequireRole returns an error unless user's role matches one of roles
+func RequireRole(user *User, roles ...string) error {
+	if user == nil {
+		return errors.New("user is nil")
+	}
+	for _, role := range roles {
+		if user.Role == role {
+			return nil
+		}
+	}
+	return errors.New("user does not have a required role")
 }
 
 // HealthCheck returns whether the auth library is properly initialized
 func HealthCheck() error {
 	return initError
 }
+
+// HealthCheckCtx returns whether the auth library is properly initialized
+// and, when backed by MongoDB, actively pings the connection using ctx.
+func HealthCheckCtx(ctx context.Context) error {
+	if initError != nil {
+		return initError
+	}
+	if mongoClient == nil {
+		return nil
+	}
+	return mongoClient.Ping(ctx, nil)
+}
+
+// Close releases resources held by the auth library, disconnecting the
+// underlying MongoDB client if one was created by InitAuthLib.
+func Close() error {
+	if mongoClient == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return mongoClient.Disconnect(ctx)
+}
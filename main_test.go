@@ -58,8 +58,8 @@ func setupTestDB(t *testing.T) *mongo.Collection {
 	}
 	log.Printf("✓ Connected to database '%s', collection 'users' has %d documents", mongoDB, count)
 
-	// Set the global collection for the library
-	mongoCollection = collection
+	// Set the global store for the library
+	userStore = NewMongoUserStore(collection)
 
 	log.Println("✓ Test database setup complete")
 	return collection
@@ -99,7 +99,7 @@ func TestHealthCheckSimple(t *testing.T) {
 	// Reset and call InitAuthLib
 	initError = nil
 	jwtSecret = ""
-	mongoCollection = nil
+	userStore = nil
 
 	// Manually call the initialization logic
 	mongoURI := os.Getenv("MONGODB_URI")
@@ -123,7 +123,7 @@ func TestHealthCheckSimple(t *testing.T) {
 		t.Fatalf("Failed to ping: %v", err)
 	}
 
-	mongoCollection = client.Database(mongoDB).Collection("users")
+	userStore = NewMongoUserStore(client.Database(mongoDB).Collection("users"))
 
 	// Now test HealthCheck
 	err = HealthCheck()
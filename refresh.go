@@ -0,0 +1,193 @@
+package pmdauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// refreshTokenAudience identifies a refresh token in the JWT's aud claim so
+// it can never be mistaken for an access token by ValidateToken.
+const refreshTokenAudience = "refresh-token"
+
+// RefreshClaims represents the JWT claims embedded in a refresh token
+type RefreshClaims struct {
+	UserID primitive.ObjectID `json:"user_id"`
+	jwt.RegisteredClaims
+}
+
+// refreshTokenRecord is the persisted record backing a refresh token
+type refreshTokenRecord struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty"`
+	Jti          string             `bson:"jti"`
+	UserID       primitive.ObjectID `bson:"user_id"`
+	TokenHash    string             `bson:"token_hash"`
+	IssuedAt     time.Time          `bson:"issued_at"`
+	ExpiresAt    time.Time          `bson:"expires_at"`
+	Revoked      bool               `bson:"revoked"`
+	AccessHours  int                `bson:"access_hours"`
+	RefreshHours int                `bson:"refresh_hours"`
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func signRefreshToken(user User, refreshHours int) (string, string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(time.Duration(refreshHours) * time.Hour)
+	jti := primitive.NewObjectID().Hex()
+
+	claims := &RefreshClaims{
+		UserID: user.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Subject:   user.ID.Hex(),
+			Audience:  jwt.ClaimStrings{refreshTokenAudience},
+		},
+	}
+
+	signed, err := signClaims(claims)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+
+	return signed, jti, expiresAt, nil
+}
+
+// GenerateTokenPair issues a short-lived access token alongside a longer-lived
+// refresh token, and persists the refresh token's record for later revocation.
+func GenerateTokenPair(user User, accessHours, refreshHours int) (access, refresh string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return GenerateTokenPairCtx(ctx, user, accessHours, refreshHours)
+}
+
+// GenerateTokenPairCtx issues a token pair like GenerateTokenPair, but lets
+// the caller control cancellation and deadlines via ctx.
+func GenerateTokenPairCtx(ctx context.Context, user User, accessHours, refreshHours int) (access, refresh string, err error) {
+	if initError != nil {
+		return "", "", initError
+	}
+
+	access, err = GenerateToken(user, accessHours)
+	if err != nil {
+		return "", "", err
+	}
+
+	refresh, jti, expiresAt, err := signRefreshToken(user, refreshHours)
+	if err != nil {
+		return "", "", err
+	}
+
+	record := refreshTokenRecord{
+		Jti:          jti,
+		UserID:       user.ID,
+		TokenHash:    hashRefreshToken(refresh),
+		IssuedAt:     time.Now(),
+		ExpiresAt:    expiresAt,
+		Revoked:      false,
+		AccessHours:  accessHours,
+		RefreshHours: refreshHours,
+	}
+
+	_, err = refreshCollection.InsertOne(ctx, record)
+	if err != nil {
+		return "", "", err
+	}
+
+	return access, refresh, nil
+}
+
+// RefreshToken exchanges a valid, unrevoked refresh token for a new access
+// token, rotating the refresh token in the process.
+func RefreshToken(refreshToken string) (newAccess, newRefresh string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return RefreshTokenCtx(ctx, refreshToken)
+}
+
+// RefreshTokenCtx exchanges a refresh token like RefreshToken, but lets the
+// caller control cancellation and deadlines via ctx.
+func RefreshTokenCtx(ctx context.Context, refreshToken string) (newAccess, newRefresh string, err error) {
+	if initError != nil {
+		return "", "", initError
+	}
+
+	claims := &RefreshClaims{}
+	_, err = jwt.ParseWithClaims(refreshToken, claims, tokenKeyFunc)
+	if err != nil {
+		return "", "", err
+	}
+
+	var record refreshTokenRecord
+	err = refreshCollection.FindOne(ctx, bson.M{"jti": claims.ID}).Decode(&record)
+	if err != nil {
+		return "", "", errors.New("refresh token not found")
+	}
+
+	if record.Revoked {
+		return "", "", errors.New("refresh token has been revoked")
+	}
+
+	if time.Now().After(record.ExpiresAt) {
+		return "", "", errors.New("refresh token has expired")
+	}
+
+	if record.TokenHash != hashRefreshToken(refreshToken) {
+		return "", "", errors.New("refresh token does not match stored record")
+	}
+
+	user, err := userStore.FindByID(ctx, record.UserID)
+	if err != nil {
+		return "", "", errors.New("user not found")
+	}
+
+	_, err = refreshCollection.UpdateOne(ctx, bson.M{"jti": record.Jti}, bson.M{"$set": bson.M{"revoked": true}})
+	if err != nil {
+		return "", "", err
+	}
+
+	newAccess, newRefresh, err = GenerateTokenPairCtx(ctx, *user, record.AccessHours, record.RefreshHours)
+	if err != nil {
+		return "", "", err
+	}
+
+	return newAccess, newRefresh, nil
+}
+
+// RevokeRefreshToken marks a refresh token as revoked so it can no longer be
+// exchanged for an access token, identified by its jti.
+func RevokeRefreshToken(tokenID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return RevokeRefreshTokenCtx(ctx, tokenID)
+}
+
+// RevokeRefreshTokenCtx revokes a refresh token like RevokeRefreshToken, but
+// lets the caller control cancellation and deadlines via ctx.
+func RevokeRefreshTokenCtx(ctx context.Context, tokenID string) error {
+	if initError != nil {
+		return initError
+	}
+
+	result, err := refreshCollection.UpdateOne(ctx, bson.M{"jti": tokenID}, bson.M{"$set": bson.M{"revoked": true}})
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return errors.New("refresh token not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,224 @@
+package pmdauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+)
+
+// Supported values for JWT_ALGORITHM / Config.Algorithm
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+)
+
+var (
+	jwtAlgorithm = AlgorithmHS256
+	signingKey   interface{} // *rsa.PrivateKey or *ecdsa.PrivateKey; unused for HS256
+	signingKid   string
+
+	verificationKeysMu sync.RWMutex
+	verificationKeys   = map[string]interface{}{} // kid -> *rsa.PublicKey or *ecdsa.PublicKey; guarded by verificationKeysMu
+)
+
+// lookupVerificationKey returns the registered public key for kid, for use
+// by tokenKeyFunc during signature verification.
+func lookupVerificationKey(kid string) (interface{}, bool) {
+	verificationKeysMu.RLock()
+	defer verificationKeysMu.RUnlock()
+	key, ok := verificationKeys[kid]
+	return key, ok
+}
+
+func setVerificationKey(kid string, key interface{}) {
+	verificationKeysMu.Lock()
+	defer verificationKeysMu.Unlock()
+	verificationKeys[kid] = key
+}
+
+// loadSigningKeys reads the PEM-encoded private/public key pair used for
+// RS256/ES256 signing from disk, derives a kid from the public key, and
+// registers it as both the active signing key and a verification key.
+func loadSigningKeys(algorithm, privateKeyPath, publicKeyPath string) error {
+	if privateKeyPath == "" || publicKeyPath == "" {
+		return errors.New("JWT_PRIVATE_KEY_PATH and JWT_PUBLIC_KEY_PATH are required for " + algorithm)
+	}
+
+	privatePEM, err := os.ReadFile(privateKeyPath)
+	if err != nil {
+		return err
+	}
+	publicPEM, err := os.ReadFile(publicKeyPath)
+	if err != nil {
+		return err
+	}
+
+	privateKey, err := parsePrivateKeyPEM(algorithm, privatePEM)
+	if err != nil {
+		return err
+	}
+	publicKey, err := parsePublicKeyPEM(algorithm, publicPEM)
+	if err != nil {
+		return err
+	}
+
+	kid, err := keyID(publicPEM)
+	if err != nil {
+		return err
+	}
+
+	jwtAlgorithm = algorithm
+	signingKey = privateKey
+	signingKid = kid
+	setVerificationKey(kid, publicKey)
+
+	return nil
+}
+
+func parsePrivateKeyPEM(algorithm string, data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM block for private key")
+	}
+
+	switch algorithm {
+	case AlgorithmRS256:
+		if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+			return key, nil
+		}
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("private key is not an RSA key")
+		}
+		return rsaKey, nil
+	case AlgorithmES256:
+		return x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+}
+
+func parsePublicKeyPEM(algorithm string, data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM block for public key")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch algorithm {
+	case AlgorithmRS256:
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("public key is not an RSA key")
+		}
+		return rsaKey, nil
+	case AlgorithmES256:
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return nil, errors.New("public key is not an ECDSA key")
+		}
+		return ecKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+}
+
+// keyID derives a stable kid from a public key's PEM bytes
+func keyID(publicPEM []byte) (string, error) {
+	sum := sha256.Sum256(publicPEM)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// AddVerificationKey registers an additional public key that can verify
+// tokens, without changing which key new tokens are signed with. This is
+// how a key can be rotated in: the new key is added here, made the signing
+// key via a deploy that points JWT_PRIVATE_KEY_PATH/JWT_PUBLIC_KEY_PATH at
+// it, and the old key stays registered here until its tokens have expired.
+func AddVerificationKey(algorithm string, publicPEM []byte) (kid string, err error) {
+	publicKey, err := parsePublicKeyPEM(algorithm, publicPEM)
+	if err != nil {
+		return "", err
+	}
+
+	kid, err = keyID(publicPEM)
+	if err != nil {
+		return "", err
+	}
+
+	setVerificationKey(kid, publicKey)
+	return kid, nil
+}
+
+// PublicJWKS returns the registered verification keys as a JWKS document,
+// suitable for serving at a /.well-known/jwks.json endpoint.
+func PublicJWKS() ([]byte, error) {
+	type jwk struct {
+		Kty string `json:"kty"`
+		Use string `json:"use"`
+		Kid string `json:"kid"`
+		Alg string `json:"alg,omitempty"`
+		N   string `json:"n,omitempty"`
+		E   string `json:"e,omitempty"`
+		Crv string `json:"crv,omitempty"`
+		X   string `json:"x,omitempty"`
+		Y   string `json:"y,omitempty"`
+	}
+
+	verificationKeysMu.RLock()
+	keySnapshot := make(map[string]interface{}, len(verificationKeys))
+	for kid, key := range verificationKeys {
+		keySnapshot[kid] = key
+	}
+	verificationKeysMu.RUnlock()
+
+	keys := make([]jwk, 0, len(keySnapshot))
+	for kid, key := range keySnapshot {
+		switch pub := key.(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, jwk{
+				Kty: "RSA",
+				Use: "sig",
+				Kid: kid,
+				Alg: AlgorithmRS256,
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			keys = append(keys, jwk{
+				Kty: "EC",
+				Use: "sig",
+				Kid: kid,
+				Alg: AlgorithmES256,
+				Crv: pub.Curve.Params().Name,
+				X:   base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size))),
+				Y:   base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size))),
+			})
+		default:
+			return nil, fmt.Errorf("unsupported key type for kid %q", kid)
+		}
+	}
+
+	return json.Marshal(struct {
+		Keys []jwk `json:"keys"`
+	}{Keys: keys})
+}
@@ -0,0 +1,118 @@
+package pmdauth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SQLUserStore is a UserStore backed by database/sql, supporting both
+// sqlite and postgres (the two placeholder styles each driver expects).
+// It assumes a `users` table shaped like:
+//
+//	id TEXT PRIMARY KEY, google_id TEXT, email TEXT, name TEXT,
+//	picture TEXT, status INTEGER, role TEXT, created_at TIMESTAMP, updated_at TIMESTAMP
+type SQLUserStore struct {
+	db      *sql.DB
+	dialect string // "postgres" or "sqlite"
+}
+
+// NewSQLUserStore wraps an existing *sql.DB as a UserStore. dialect selects
+// the placeholder style used when building queries ("postgres" or "sqlite").
+func NewSQLUserStore(db *sql.DB, dialect string) *SQLUserStore {
+	return &SQLUserStore{db: db, dialect: dialect}
+}
+
+func (s *SQLUserStore) placeholder(n int) string {
+	if s.dialect == "postgres" {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
+
+func (s *SQLUserStore) scanUser(row *sql.Row) (*User, error) {
+	var user User
+	var idHex string
+
+	err := row.Scan(&idHex, &user.GoogleID, &user.Email, &user.Name, &user.Picture,
+		&user.Status, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user.ID, err = primitive.ObjectIDFromHex(idHex)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// FindByID implements UserStore
+func (s *SQLUserStore) FindByID(ctx context.Context, id primitive.ObjectID) (*User, error) {
+	query := fmt.Sprintf(`SELECT id, google_id, email, name, picture, status, role, created_at, updated_at
+		FROM users WHERE id = %s`, s.placeholder(1))
+	row := s.db.QueryRowContext(ctx, query, id.Hex())
+	return s.scanUser(row)
+}
+
+// FindByEmail implements UserStore
+func (s *SQLUserStore) FindByEmail(ctx context.Context, email string) (*User, error) {
+	query := fmt.Sprintf(`SELECT id, google_id, email, name, picture, status, role, created_at, updated_at
+		FROM users WHERE email = %s`, s.placeholder(1))
+	row := s.db.QueryRowContext(ctx, query, email)
+	return s.scanUser(row)
+}
+
+// Insert implements UserStore
+func (s *SQLUserStore) Insert(ctx context.Context, user *User) error {
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now()
+	}
+	user.UpdatedAt = time.Now()
+
+	query := fmt.Sprintf(`INSERT INTO users (id, google_id, email, name, picture, status, role, created_at, updated_at)
+		VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s)`,
+		s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4),
+		s.placeholder(5), s.placeholder(6), s.placeholder(7), s.placeholder(8), s.placeholder(9))
+
+	_, err := s.db.ExecContext(ctx, query, user.ID.Hex(), user.GoogleID, user.Email, user.Name,
+		user.Picture, user.Status, user.Role, user.CreatedAt, user.UpdatedAt)
+	return err
+}
+
+// Update implements UserStore
+func (s *SQLUserStore) Update(ctx context.Context, user *User) error {
+	user.UpdatedAt = time.Now()
+
+	query := fmt.Sprintf(`UPDATE users SET google_id = %s, email = %s, name = %s, picture = %s,
+		status = %s, role = %s, updated_at = %s WHERE id = %s`,
+		s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4),
+		s.placeholder(5), s.placeholder(6), s.placeholder(7), s.placeholder(8))
+
+	result, err := s.db.ExecContext(ctx, query, user.GoogleID, user.Email, user.Name,
+		user.Picture, user.Status, user.Role, user.UpdatedAt, user.ID.Hex())
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package pmdauth
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrUserNotFound is returned by a UserStore when no matching user exists
+var ErrUserNotFound = errors.New("user not found")
+
+// UserStore decouples user persistence from any particular datastore so
+// consumers can plug in their own backing store instead of being forced
+// onto MongoDB.
+type UserStore interface {
+	FindByID(ctx context.Context, id primitive.ObjectID) (*User, error)
+	FindByEmail(ctx context.Context, email string) (*User, error)
+	Insert(ctx context.Context, user *User) error
+	Update(ctx context.Context, user *User) error
+}
+
+// userStore is the active UserStore backing the package-level functions,
+// set by InitAuthLib or InitAuthLibWithConfig.
+var userStore UserStore
+
+// ensureUserIndexes creates the indexes the users collection is queried by
+// (email lookups and Google SSO lookups) if they don't already exist.
+func ensureUserIndexes(ctx context.Context, collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "google_id", Value: 1}},
+		},
+	})
+	return err
+}
+
+// MongoUserStore is a UserStore backed by a MongoDB collection
+type MongoUserStore struct {
+	collection *mongo.Collection
+}
+
+// NewMongoUserStore wraps an existing users collection as a UserStore
+func NewMongoUserStore(collection *mongo.Collection) *MongoUserStore {
+	return &MongoUserStore{collection: collection}
+}
+
+// FindByID implements UserStore
+func (s *MongoUserStore) FindByID(ctx context.Context, id primitive.ObjectID) (*User, error) {
+	var user User
+	err := s.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindByEmail implements UserStore
+func (s *MongoUserStore) FindByEmail(ctx context.Context, email string) (*User, error) {
+	var user User
+	err := s.collection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Insert implements UserStore
+func (s *MongoUserStore) Insert(ctx context.Context, user *User) error {
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	_, err := s.collection.InsertOne(ctx, user)
+	return err
+}
+
+// Update implements UserStore
+func (s *MongoUserStore) Update(ctx context.Context, user *User) error {
+	_, err := s.collection.ReplaceOne(ctx, bson.M{"_id": user.ID}, user)
+	return err
+}
+
+// InMemoryUserStore is a UserStore backed by an in-process map, useful for
+// tests and for consumers that don't need durable storage.
+type InMemoryUserStore struct {
+	mu      sync.RWMutex
+	byID    map[primitive.ObjectID]User
+	byEmail map[string]primitive.ObjectID
+}
+
+// NewInMemoryUserStore returns an empty InMemoryUserStore
+func NewInMemoryUserStore() *InMemoryUserStore {
+	return &InMemoryUserStore{
+		byID:    make(map[primitive.ObjectID]User),
+		byEmail: make(map[string]primitive.ObjectID),
+	}
+}
+
+// FindByID implements UserStore
+func (s *InMemoryUserStore) FindByID(ctx context.Context, id primitive.ObjectID) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.byID[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return &user, nil
+}
+
+// FindByEmail implements UserStore
+func (s *InMemoryUserStore) FindByEmail(ctx context.Context, email string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.byEmail[email]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	user := s.byID[id]
+	return &user, nil
+}
+
+// Insert implements UserStore
+func (s *InMemoryUserStore) Insert(ctx context.Context, user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	s.byID[user.ID] = *user
+	s.byEmail[user.Email] = user.ID
+	return nil
+}
+
+// Update implements UserStore
+func (s *InMemoryUserStore) Update(ctx context.Context, user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.byID[user.ID]; !ok {
+		return ErrUserNotFound
+	}
+	s.byID[user.ID] = *user
+	s.byEmail[user.Email] = user.ID
+	return nil
+}